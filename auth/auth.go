@@ -0,0 +1,64 @@
+//Package auth implements the Proxy-Authorization / SOCKS5 username-password
+//checks enforced by the HTTP and SOCKS5 listeners in cmd/main.go, plus the
+//failed-attempt rate limiting and metric shared between them.
+package auth
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//FailureLimit is how many failed attempts a client gets within
+	//FailureWindow before further attempts are rejected outright.
+	FailureLimit = 5
+	//FailureWindow is the sliding window failed attempts are counted over.
+	FailureWindow = time.Minute
+)
+
+var failedAttempts int64
+
+//FailedAttempts returns the total number of failed auth attempts seen since
+//start, surfaced by the controller as a metric.
+func FailedAttempts() int64 {
+	return atomic.LoadInt64(&failedAttempts)
+}
+
+//Limiter rate-limits repeated failed auth attempts per client address.
+type Limiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+//NewLimiter returns a ready-to-use Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{failures: make(map[string][]time.Time)}
+}
+
+//Allow reports whether client is still under FailureLimit within FailureWindow.
+func (l *Limiter) Allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := time.Now().Add(-FailureWindow)
+	kept := l.failures[client][:0]
+	for _, t := range l.failures[client] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.failures, client)
+		return true
+	}
+	l.failures[client] = kept
+	return len(kept) < FailureLimit
+}
+
+//Fail records a failed attempt from client and bumps the process-wide metric.
+func (l *Limiter) Fail(client string) {
+	l.mu.Lock()
+	l.failures[client] = append(l.failures[client], time.Now())
+	l.mu.Unlock()
+	atomic.AddInt64(&failedAttempts, 1)
+}
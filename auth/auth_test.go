@@ -0,0 +1,42 @@
+package auth
+
+import "testing"
+
+func TestLimiterAllowEvictsEmptyEntries(t *testing.T) {
+	l := NewLimiter()
+	for i := 0; i < FailureLimit; i++ {
+		l.Fail("1.2.3.4")
+	}
+	if l.Allow("1.2.3.4") {
+		t.Fatalf("Allow should reject a client at FailureLimit")
+	}
+
+	// Force the window to have elapsed by clearing the recorded failures
+	// directly, simulating FailureWindow having passed.
+	l.mu.Lock()
+	l.failures["1.2.3.4"] = nil
+	l.mu.Unlock()
+
+	if !l.Allow("1.2.3.4") {
+		t.Fatalf("Allow should accept once failures have aged out")
+	}
+	l.mu.Lock()
+	_, present := l.failures["1.2.3.4"]
+	l.mu.Unlock()
+	if present {
+		t.Fatalf("Allow should delete the map entry once it empties out, got %v", l.failures)
+	}
+}
+
+func TestLimiterKeyedPerClientNotPerConnection(t *testing.T) {
+	l := NewLimiter()
+	for i := 0; i < FailureLimit; i++ {
+		l.Fail("1.2.3.4")
+	}
+	if l.Allow("1.2.3.4") {
+		t.Fatalf("client with FailureLimit failures should be blocked")
+	}
+	if !l.Allow("5.6.7.8") {
+		t.Fatalf("a different client must not be affected by another client's failures")
+	}
+}
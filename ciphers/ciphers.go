@@ -0,0 +1,80 @@
+//Package ciphers implements the stream ciphers the "ss" protocol uses to
+//frame traffic to a shadowsocks server.
+package ciphers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+//StreamCipher describes a shadowsocks stream cipher: its key/IV sizes and
+//how to build an encrypting or decrypting stream.Cipher once key and IV
+//(the connection's own random IV, or the one read off the peer) are known.
+type StreamCipher struct {
+	KeySize      int
+	IVSize       int
+	NewEncrypter func(key, iv []byte) (cipher.Stream, error)
+	NewDecrypter func(key, iv []byte) (cipher.Stream, error)
+}
+
+var ciphers = map[string]StreamCipher{
+	"aes-128-cfb": newAESCFBCipher(16),
+	"aes-192-cfb": newAESCFBCipher(24),
+	"aes-256-cfb": newAESCFBCipher(32),
+}
+
+func newAESCFBCipher(keySize int) StreamCipher {
+	return StreamCipher{
+		KeySize: keySize,
+		IVSize:  aes.BlockSize,
+		NewEncrypter: func(key, iv []byte) (cipher.Stream, error) {
+			block, err := aes.NewCipher(key)
+			if err != nil {
+				return nil, err
+			}
+			return cipher.NewCFBEncrypter(block, iv), nil
+		},
+		NewDecrypter: func(key, iv []byte) (cipher.Stream, error) {
+			block, err := aes.NewCipher(key)
+			if err != nil {
+				return nil, err
+			}
+			return cipher.NewCFBDecrypter(block, iv), nil
+		},
+	}
+}
+
+//Get returns the registered StreamCipher for method, e.g. "aes-256-cfb".
+func Get(method string) (StreamCipher, bool) {
+	c, ok := ciphers[method]
+	return c, ok
+}
+
+//Kdf derives a keySize-byte key from password the same way the original
+//shadowsocks implementations do (OpenSSL's EVP_BytesToKey with MD5, no salt).
+func Kdf(password string, keySize int) []byte {
+	var out []byte
+	var prev []byte
+	for len(out) < keySize {
+		h := md5.New()
+		h.Write(prev)
+		h.Write([]byte(password))
+		prev = h.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:keySize]
+}
+
+//RandomIV returns n cryptographically random bytes, suitable as a per-
+//connection IV.
+func RandomIV(n int) ([]byte, error) {
+	iv := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, fmt.Errorf("ciphers: random iv: %w", err)
+	}
+	return iv, nil
+}
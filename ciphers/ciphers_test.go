@@ -0,0 +1,81 @@
+package ciphers
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	for _, method := range []string{"aes-128-cfb", "aes-192-cfb", "aes-256-cfb"} {
+		c, ok := Get(method)
+		if !ok {
+			t.Errorf("Get(%q) not found", method)
+			continue
+		}
+		if c.KeySize <= 0 || c.IVSize <= 0 {
+			t.Errorf("Get(%q) = %+v; want positive KeySize/IVSize", method, c)
+		}
+	}
+	if _, ok := Get("rot13"); ok {
+		t.Error("Get(rot13) should not be found")
+	}
+}
+
+func TestKdfDeterministicAndSized(t *testing.T) {
+	k1 := Kdf("hunter2", 32)
+	k2 := Kdf("hunter2", 32)
+	if len(k1) != 32 {
+		t.Fatalf("len(Kdf) = %d, want 32", len(k1))
+	}
+	if string(k1) != string(k2) {
+		t.Error("Kdf should be deterministic for the same password/size")
+	}
+	if string(Kdf("other", 32)) == string(k1) {
+		t.Error("Kdf should differ for different passwords")
+	}
+}
+
+func TestRandomIV(t *testing.T) {
+	iv1, err := RandomIV(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iv1) != 16 {
+		t.Fatalf("len(RandomIV) = %d, want 16", len(iv1))
+	}
+	iv2, _ := RandomIV(16)
+	if string(iv1) == string(iv2) {
+		t.Error("two calls to RandomIV should not produce the same bytes")
+	}
+}
+
+func TestAESCFBRoundTrip(t *testing.T) {
+	c, ok := Get("aes-256-cfb")
+	if !ok {
+		t.Fatal("aes-256-cfb not registered")
+	}
+	key := Kdf("password", c.KeySize)
+	iv, err := RandomIV(c.IVSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := c.NewEncrypter(key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := c.NewDecrypter(key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := []byte("the quick brown fox jumps over the lazy dog")
+	cipherText := make([]byte, len(plain))
+	enc.XORKeyStream(cipherText, plain)
+	if string(cipherText) == string(plain) {
+		t.Fatal("ciphertext must not equal plaintext")
+	}
+
+	decoded := make([]byte, len(cipherText))
+	dec.XORKeyStream(decoded, cipherText)
+	if string(decoded) != string(plain) {
+		t.Fatalf("round trip = %q, want %q", decoded, plain)
+	}
+}
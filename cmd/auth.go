@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/sipt/shuttle/auth"
+)
+
+var authLimiter = auth.NewLimiter()
+
+//clientHost returns conn's remote IP without its (per-connection, never
+//reused) port, so the rate limiter keys on the actual client rather than a
+//value that's different on every single connection.
+func clientHost(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+//authedConn replays the raw bytes httpAuthenticate already consumed off conn
+//while peeking at its headers before the rest of conn is handed off to
+//shuttle. socks5Authenticate does not use it: the handshake bytes it reads
+//are not replayed.
+type authedConn struct {
+	net.Conn
+	buf *bytes.Reader
+	br  *bufio.Reader
+}
+
+func (c *authedConn) Read(p []byte) (int, error) {
+	if c.buf != nil {
+		if n, err := c.buf.Read(p); err != io.EOF {
+			return n, err
+		}
+		c.buf = nil
+	}
+	return c.br.Read(p)
+}
+
+//httpAuthenticate enforces Proxy-Authorization: Basic on conn when username
+//is set. It returns the connection to keep using (with its already-read
+//request line/headers replayed) and whether the caller should keep serving it.
+func httpAuthenticate(conn net.Conn, username, password string) (net.Conn, bool) {
+	if username == "" {
+		return conn, true
+	}
+	br := bufio.NewReader(conn)
+	var raw bytes.Buffer
+	var proxyAuth string
+	for {
+		line, err := br.ReadString('\n')
+		raw.WriteString(line)
+		if err != nil {
+			return conn, false
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "" {
+			break
+		}
+		if idx := strings.IndexByte(trimmed, ':'); idx > 0 {
+			if strings.EqualFold(strings.TrimSpace(trimmed[:idx]), "Proxy-Authorization") {
+				proxyAuth = strings.TrimSpace(trimmed[idx+1:])
+			}
+		}
+	}
+	client := clientHost(conn)
+	if !authLimiter.Allow(client) || !verifyBasicAuth(proxyAuth, username, password) {
+		authLimiter.Fail(client)
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n"+
+			"Proxy-Authenticate: Basic realm=\"shuttle\"\r\n\r\n")
+		return conn, false
+	}
+	return &authedConn{Conn: conn, buf: bytes.NewReader(raw.Bytes()), br: br}, true
+}
+
+func verifyBasicAuth(header, username, password string) bool {
+	const prefix = "Basic "
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return false
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	return len(parts) == 2 && parts[0] == username && parts[1] == password
+}
+
+//socks5Authenticate performs the SOCKS5 method negotiation (RFC 1928) and,
+//when username is set, the username/password sub-negotiation (RFC 1929).
+//It returns whether conn may proceed to the SOCKS5 request phase.
+func socks5Authenticate(conn net.Conn, username, password string) bool {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 0x05 {
+		return false
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return false
+	}
+
+	wants := byte(0x00)
+	if username != "" {
+		wants = 0x02
+	}
+	offered := false
+	for _, m := range methods {
+		if m == wants {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		conn.Write([]byte{0x05, 0xff})
+		return false
+	}
+	if _, err := conn.Write([]byte{0x05, wants}); err != nil {
+		return false
+	}
+	if username == "" {
+		return true
+	}
+
+	sub := make([]byte, 2)
+	if _, err := io.ReadFull(conn, sub); err != nil || sub[0] != 0x01 {
+		return false
+	}
+	uname := make([]byte, sub[1])
+	if _, err := io.ReadFull(conn, uname); err != nil {
+		return false
+	}
+	plen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, plen); err != nil {
+		return false
+	}
+	passwd := make([]byte, plen[0])
+	if _, err := io.ReadFull(conn, passwd); err != nil {
+		return false
+	}
+
+	client := clientHost(conn)
+	ok := authLimiter.Allow(client) && string(uname) == username && string(passwd) == password
+	status := byte(0x00)
+	if !ok {
+		authLimiter.Fail(client)
+		status = 0x01
+	}
+	if _, err := conn.Write([]byte{0x01, status}); err != nil {
+		return false
+	}
+	return ok
+}
@@ -12,9 +12,12 @@ import (
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+
 	"github.com/sipt/shuttle"
 	"github.com/sipt/shuttle/config"
 	"github.com/sipt/shuttle/controller"
@@ -29,14 +32,37 @@ import (
 	_ "github.com/sipt/shuttle/proxy/selector"
 )
 
+//reloadDebounce is how long the config watcher waits for writes to settle
+//(editors often truncate-then-write, firing several fsnotify events per save)
+//before actually reloading.
+const reloadDebounce = 200 * time.Millisecond
+
 var (
-	ShutdownSignal     = make(chan bool, 1)
-	UpgradeSignal      = make(chan string, 1)
-	StopSocksSignal    = make(chan bool, 1)
-	StopHTTPSignal     = make(chan bool, 1)
-	ReloadConfigSignal = make(chan bool, 1)
+	ShutdownSignal  = make(chan bool, 1)
+	UpgradeSignal   = make(chan string, 1)
+	StopSocksSignal = make(chan bool, 1)
+	StopHTTPSignal  = make(chan bool, 1)
 )
 
+//confHolder guards the *config.Config currently in effect so the fsnotify
+//watcher goroutine and main() can both read/replace it safely.
+type confHolder struct {
+	mu   sync.Mutex
+	conf *config.Config
+}
+
+func (h *confHolder) Get() *config.Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.conf
+}
+
+func (h *confHolder) Set(conf *config.Config) {
+	h.mu.Lock()
+	h.conf = conf
+	h.mu.Unlock()
+}
+
 func main() {
 	configPath := flag.String("c", "shuttle.yaml", "configuration file path")
 	logMode := flag.String("l", "file", "logMode: off | console | file")
@@ -81,15 +107,17 @@ func main() {
 		return
 	}
 
+	holder := &confHolder{conf: conf}
+
 	// 启动api控制
-	go controller.StartController(conf,
-		ShutdownSignal,     // shutdown program
-		ReloadConfigSignal, // reload config
-		UpgradeSignal,      // upgrade
+	go controller.StartController(holder.Get,
+		ShutdownSignal, // shutdown program
+		UpgradeSignal,  // upgrade
 	)
 	//go HandleUDP()
 	go HandleHTTP(conf, StopSocksSignal)
 	go HandleSocks5(conf, StopHTTPSignal)
+	go watchConfig(*configPath, holder)
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 	if conf.General.SetAsSystemProxy == "" || conf.General.SetAsSystemProxy == config.SetAsSystemProxyAuto {
@@ -100,7 +128,7 @@ func main() {
 	for {
 		select {
 		case fileName := <-UpgradeSignal:
-			shutdown(conf.General.SetAsSystemProxy)
+			shutdown(holder.Get().General.SetAsSystemProxy)
 			log.Logger.Info("[Shuttle] is shutdown, for upgrade!")
 			var name string
 			if runtime.GOOS == "windows" {
@@ -117,31 +145,112 @@ func main() {
 			os.Exit(0)
 		case <-ShutdownSignal:
 			log.Logger.Info("[Shuttle] is shutdown, see you later!")
-			shutdown(conf.General.SetAsSystemProxy)
+			shutdown(holder.Get().General.SetAsSystemProxy)
 			os.Exit(0)
 			return
 		case <-signalChan:
 			log.Logger.Info("[Shuttle] is shutdown, see you later!")
-			shutdown(conf.General.SetAsSystemProxy)
+			shutdown(holder.Get().General.SetAsSystemProxy)
 			os.Exit(0)
 			return
-		case <-ReloadConfigSignal:
-			StopSocksSignal <- true
-			StopHTTPSignal <- true
-			conf, err = config.ReloadConfig()
-			if err != nil {
-				log.Logger.Error("Reload Config failed: ", err)
+		}
+	}
+}
+
+//watchConfig watches configPath (and reacts to editors that replace the file
+//via rename-into-place) and reloads it on change, debouncing bursts of
+//fsnotify events down to a single reload.
+func watchConfig(configPath string, holder *confHolder) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Logger.Error("[Shuttle] fsnotify: ", err)
+		return
+	}
+	defer watcher.Close()
+	// Watch the containing directory rather than the file itself so renames
+	// (atomic-save editors) and recreates are still picked up.
+	dir := filepath.Dir(configPath)
+	if err = watcher.Add(dir); err != nil {
+		log.Logger.Error("[Shuttle] fsnotify: ", err)
+		return
+	}
+	target := filepath.Clean(configPath)
+	var timer *time.Timer
+	reload := make(chan bool, 1)
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != target {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
 			}
-			if conf.General.SetAsSystemProxy == "" || conf.General.SetAsSystemProxy == config.SetAsSystemProxyAuto {
-				//enable system proxy
-				EnableSystemProxy(conf)
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDebounce, func() {
+				select {
+				case reload <- true:
+				default:
+				}
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
 			}
-			go HandleHTTP(conf, StopSocksSignal)
-			go HandleSocks5(conf, StopHTTPSignal)
+			log.Logger.Error("[Shuttle] fsnotify: ", err)
+		case <-reload:
+			reloadConfig(holder)
 		}
 	}
 }
 
+//reloadConfig re-reads the config file and re-applies DNS, proxy, rule,
+//HTTP-modify and MITM configs in place. The SOCKS/HTTP listeners are only
+//bounced when their bind address actually changed.
+func reloadConfig(holder *confHolder) {
+	prev := holder.Get()
+	conf, err := config.ReloadConfig()
+	if err != nil {
+		log.Logger.Error("[Shuttle] reload config failed: ", err)
+		return
+	}
+	shuttle.InitConfigValue(conf)
+	if err = dns.ApplyConfig(conf); err != nil {
+		log.Logger.Error("[Shuttle] reload dns failed: ", err)
+	}
+	if err = proxy.ApplyConfig(conf); err != nil {
+		log.Logger.Error("[Shuttle] reload proxy failed: ", err)
+	}
+	if err = rule.ApplyConfig(conf); err != nil {
+		log.Logger.Error("[Shuttle] reload rule failed: ", err)
+	}
+	if err = shuttle.ApplyHTTPModifyConfig(conf); err != nil {
+		log.Logger.Error("[Shuttle] reload http-modify failed: ", err)
+	}
+	if err = shuttle.ApplyMITMConfig(conf); err != nil {
+		log.Logger.Error("[Shuttle] reload mitm failed: ", err)
+	}
+	holder.Set(conf)
+
+	if conf.GetHTTPInterface() != prev.GetHTTPInterface() || conf.GetHTTPPort() != prev.GetHTTPPort() {
+		StopSocksSignal <- true
+		go HandleHTTP(conf, StopSocksSignal)
+	}
+	if conf.GetSOCKSInterface() != prev.GetSOCKSInterface() || conf.GetSOCKSPort() != prev.GetSOCKSPort() {
+		StopHTTPSignal <- true
+		go HandleSocks5(conf, StopHTTPSignal)
+	}
+	if conf.General.SetAsSystemProxy == "" || conf.General.SetAsSystemProxy == config.SetAsSystemProxyAuto {
+		EnableSystemProxy(conf)
+	}
+	log.Logger.Info("[Shuttle] config reloaded")
+}
+
 func shutdown(setAsSystemProxy string) {
 	StopSocksSignal <- true
 	StopHTTPSignal <- true
@@ -177,6 +286,8 @@ type ISOCKSProxyConfig interface {
 	SetSOCKSInterface(string)
 	GetSOCKSPort() string
 	SetSOCKSPort(string)
+	GetUsername() string
+	GetPassword() string
 }
 
 func HandleSocks5(config ISOCKSProxyConfig, stopHandle chan bool) {
@@ -212,6 +323,11 @@ func HandleSocks5(config ISOCKSProxyConfig, stopHandle chan bool) {
 					conn.Close()
 				}
 			}()
+			if !socks5Authenticate(conn, config.GetUsername(), config.GetPassword()) {
+				log.Logger.Debug("[SOCKS]auth failed, closing connection")
+				conn.Close()
+				return
+			}
 			log.Logger.Debug("[SOCKS]Accept tcp connection")
 			shuttle.SocksHandle(conn)
 		}()
@@ -224,6 +340,8 @@ type IHTTPProxyConfig interface {
 	SetHTTPInterface(string)
 	GetHTTPPort() string
 	SetHTTPPort(string)
+	GetUsername() string
+	GetPassword() string
 }
 
 func HandleHTTP(config IHTTPProxyConfig, stopHandle chan bool) {
@@ -260,8 +378,13 @@ func HandleHTTP(config IHTTPProxyConfig, stopHandle chan bool) {
 					log.Logger.Errorf("[HTTP/HTTPS]stack :%s", debug.Stack())
 				}
 			}()
+			authed, ok := httpAuthenticate(conn, config.GetUsername(), config.GetPassword())
+			if !ok {
+				log.Logger.Debug("[HTTP/HTTPS]auth failed, closing connection")
+				return
+			}
 			log.Logger.Debug("[HTTP/HTTPS]Accept tcp connection")
-			shuttle.HandleHTTP(conn)
+			shuttle.HandleHTTP(authed)
 		}()
 	}
 }
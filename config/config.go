@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SetAsSystemProxyAuto = "auto"
+	SetAsSystemProxyNone = "none"
+)
+
+var (
+	mu         sync.Mutex
+	configPath string
+)
+
+//General holds the [general] section of the yaml config
+type General struct {
+	HTTPInterface    string `yaml:"http_interface"`
+	HTTPPort         string `yaml:"http_port"`
+	SOCKSInterface   string `yaml:"socks_interface"`
+	SOCKSPort        string `yaml:"socks_port"`
+	SetAsSystemProxy string `yaml:"set_as_system_proxy"`
+	//Username, when set, requires Proxy-Authorization (HTTP) or RFC 1929
+	//username/password auth (SOCKS5) on the respective listener, checked
+	//against Username and Password (an empty Password is then expected as-is).
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	//DirectDomains lists suffix/wildcard/"/regex/" host patterns that always
+	//go DIRECT, regardless of which proxy group a rule would otherwise pick.
+	DirectDomains []string `yaml:"direct_domains"`
+}
+
+//Config is the root of the yaml configuration file
+type Config struct {
+	General *General `yaml:"general"`
+	DNS     *DNS     `yaml:"dns"`
+	Proxy   *Proxy   `yaml:"proxy"`
+	Rule    *Rule    `yaml:"rule"`
+	MITM    *MITM    `yaml:"mitm"`
+}
+
+//GetHTTPInterface implements main.IHTTPProxyConfig
+func (c *Config) GetHTTPInterface() string { return c.General.HTTPInterface }
+
+//SetHTTPInterface implements main.IHTTPProxyConfig
+func (c *Config) SetHTTPInterface(v string) { c.General.HTTPInterface = v }
+
+//GetHTTPPort implements main.IHTTPProxyConfig
+func (c *Config) GetHTTPPort() string { return c.General.HTTPPort }
+
+//SetHTTPPort implements main.IHTTPProxyConfig
+func (c *Config) SetHTTPPort(v string) { c.General.HTTPPort = v }
+
+//GetSOCKSInterface implements main.ISOCKSProxyConfig
+func (c *Config) GetSOCKSInterface() string { return c.General.SOCKSInterface }
+
+//SetSOCKSInterface implements main.ISOCKSProxyConfig
+func (c *Config) SetSOCKSInterface(v string) { c.General.SOCKSInterface = v }
+
+//GetSOCKSPort implements main.ISOCKSProxyConfig
+func (c *Config) GetSOCKSPort() string { return c.General.SOCKSPort }
+
+//SetSOCKSPort implements main.ISOCKSProxyConfig
+func (c *Config) SetSOCKSPort(v string) { c.General.SOCKSPort = v }
+
+//GetUsername implements main.IHTTPProxyConfig and main.ISOCKSProxyConfig
+func (c *Config) GetUsername() string { return c.General.Username }
+
+//GetPassword implements main.IHTTPProxyConfig and main.ISOCKSProxyConfig
+func (c *Config) GetPassword() string { return c.General.Password }
+
+//LoadConfig reads and parses the yaml config file at path, remembering the
+//path so a later ReloadConfig can re-read it.
+func LoadConfig(path string) (*Config, error) {
+	mu.Lock()
+	configPath = path
+	mu.Unlock()
+	return load(path)
+}
+
+//ReloadConfig re-reads the config file last loaded with LoadConfig.
+func ReloadConfig() (*Config, error) {
+	mu.Lock()
+	path := configPath
+	mu.Unlock()
+	if path == "" {
+		return nil, fmt.Errorf("config: ReloadConfig called before LoadConfig")
+	}
+	return load(path)
+}
+
+//ConfigPath returns the path of the config file currently loaded.
+func ConfigPath() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return configPath
+}
+
+func load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: read %s: %w", path, err)
+	}
+	conf := &Config{}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	if conf.General == nil {
+		conf.General = &General{}
+	}
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		mu.Lock()
+		configPath = abs
+		mu.Unlock()
+	}
+	return conf, nil
+}
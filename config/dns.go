@@ -0,0 +1,7 @@
+package config
+
+//DNS holds the [dns] section of the yaml config
+type DNS struct {
+	Servers []string `yaml:"servers"`
+	GeoIP   string   `yaml:"geoip"`
+}
@@ -0,0 +1,18 @@
+package config
+
+//HostHandler is one entry of an ordered per-host dispatch list: any request
+//whose host matches HostRegex is handled by Action before rule-based routing
+//runs. Script, when set, is the path of a line-oriented rewrite spec (see
+//scriptSpec in script.go) that the "mitm" and "hijack" actions load; Lua and
+//starlark scripts are not executed.
+type HostHandler struct {
+	HostRegex string `yaml:"host_regex"`
+	//Action is one of "mitm", "reject", "direct", "hijack".
+	Action string `yaml:"action"`
+	Script string `yaml:"script"`
+}
+
+//MITM holds the [mitm] section of the yaml config
+type MITM struct {
+	Handlers []*HostHandler `yaml:"handlers"`
+}
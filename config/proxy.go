@@ -0,0 +1,43 @@
+package config
+
+//ProxyEntry describes a single upstream proxy entry under the [proxy] section
+type ProxyEntry struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Server string `yaml:"server"`
+	Port   string `yaml:"port"`
+	//TLS dials Server with TLS before speaking the entry's protocol to it.
+	//Used by the "http" protocol to reach a parent HTTPS CONNECT proxy.
+	TLS bool `yaml:"tls"`
+	//Username/Password are used by the "socks5" protocol for RFC1929
+	//sub-negotiation when set; Username empty means "no auth".
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	//Method selects the "ss" protocol's stream cipher, e.g. "aes-256-cfb".
+	Method string `yaml:"method"`
+}
+
+//ProxyGroup describes a group of proxy entries selected by a selector
+type ProxyGroup struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type"`
+	Proxies []string `yaml:"proxies"`
+	//TestURL, when set, is additionally fetched through each member proxy on
+	//every health check, on top of the plain TCP connect check.
+	TestURL string `yaml:"test_url"`
+	//BypassDomains lists suffix/wildcard/"/regex/" host patterns that are
+	//routed DIRECT instead of through this group.
+	BypassDomains []string `yaml:"bypass_domains"`
+}
+
+//Proxy holds the [proxy] section of the yaml config
+type Proxy struct {
+	Entries []*ProxyEntry `yaml:"list"`
+	Groups  []*ProxyGroup `yaml:"group"`
+	//Checkers bounds how many proxies are health-checked concurrently.
+	Checkers int `yaml:"proxy_checkers"`
+	//ConnectTimeout bounds a single health-check dial/fetch, e.g. "5s".
+	ConnectTimeout string `yaml:"proxy_connect_timeout"`
+	//HealthCheckInterval is the period between health-check rounds, e.g. "30s".
+	HealthCheckInterval string `yaml:"health_check_interval"`
+}
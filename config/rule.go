@@ -0,0 +1,17 @@
+package config
+
+//GFWList configures the optional GFWList/AutoProxy rule provider: a
+//Base64-encoded AutoProxy file fetched from URL, cached on disk and
+//refreshed every Duration, with every blacklist entry routed through Proxy.
+type GFWList struct {
+	URL       string `yaml:"url"`
+	Proxy     string `yaml:"proxy"`
+	Duration  string `yaml:"duration"`
+	CachePath string `yaml:"cache_path"`
+}
+
+//Rule holds the [rule] section of the yaml config
+type Rule struct {
+	List    []string `yaml:"list"`
+	GFWList *GFWList `yaml:"gfwlist"`
+}
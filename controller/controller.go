@@ -0,0 +1,53 @@
+//Package controller exposes a local HTTP API used by the shuttle CLI/UI to
+//inspect and control a running instance (shutdown, upgrade, ...).
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sipt/shuttle/auth"
+	"github.com/sipt/shuttle/config"
+	"github.com/sipt/shuttle/log"
+	"github.com/sipt/shuttle/proxy"
+)
+
+//StartController serves the controller HTTP API until the process exits.
+//shutdownSignal/upgradeSignal mirror the channels main() selects on. Config
+//reload is no longer driven from here: it is handled by an fsnotify watcher
+//on the config file in cmd/main.go. confGetter returns the config currently
+//in effect, so handlers that bake in config-derived values (e.g. /proxy.pac)
+//pick up a reload instead of keeping the config from process start.
+func StartController(confGetter func() *config.Config, shutdownSignal chan bool, upgradeSignal chan string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
+		shutdownSignal <- true
+	})
+	mux.HandleFunc("/proxies", handleProxies)
+	mux.HandleFunc("/proxy.pac", handlePAC(confGetter))
+	mux.HandleFunc("/auth-stats", handleAuthStats)
+	addr := confGetter().General.HTTPInterface + ":10887"
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Logger.Error("[controller] ", err)
+	}
+}
+
+//handleProxies reports the health of every configured proxy, so a UI can
+//render the pool and why a group is (or isn't) failing over.
+func handleProxies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(proxy.HealthSnapshot()); err != nil {
+		log.Logger.Error("[controller] /proxies: ", err)
+	}
+}
+
+//handleAuthStats reports the total number of failed Proxy-Authorization/
+//SOCKS5 auth attempts the listeners have rejected since start.
+func handleAuthStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		FailedAttempts int64 `json:"failed_attempts"`
+	}{auth.FailedAttempts()}); err != nil {
+		log.Logger.Error("[controller] /auth-stats: ", err)
+	}
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sipt/shuttle/config"
+	"github.com/sipt/shuttle/rule"
+)
+
+const pacTemplate = `function FindProxyForURL(url, host) {
+%s
+	return "DIRECT";
+}
+`
+
+//handlePAC generates a PAC script from the current effective ruleset,
+//pointing every non-DIRECT rule at the local HTTP listener. confGetter is
+//called per-request (rather than once at startup) so the advertised address
+//follows a config hot-reload that changes http_port.
+func handlePAC(confGetter func() *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		localHTTPAddr := net.JoinHostPort("127.0.0.1", confGetter().GetHTTPPort())
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		fmt.Fprintf(w, pacTemplate, pacRules(localHTTPAddr))
+	}
+}
+
+func pacRules(localHTTPAddr string) string {
+	var b strings.Builder
+	for _, rl := range rule.Snapshot() {
+		fmt.Fprintf(&b, "\tif (%s) { return %q; }\n", pacCondition(rl), pacAction(rl, localHTTPAddr))
+	}
+	return b.String()
+}
+
+func pacCondition(rl *rule.Rule) string {
+	switch rl.Type {
+	case "DOMAIN":
+		return fmt.Sprintf("host == %q", rl.Value)
+	case "DOMAIN-SUFFIX":
+		return fmt.Sprintf("shExpMatch(host, %q)", "*"+rl.Value)
+	case "DOMAIN-REGEX":
+		// PAC has no native regex matcher. GFWList regex entries are almost
+		// always an escaped literal domain (e.g. `facebook\.com`), so fall
+		// back to a substring match against the pattern with its backslash
+		// escapes stripped; anything genuinely regex-like still won't match
+		// and falls through to DIRECT.
+		literal := strings.ReplaceAll(rl.Value, `\`, "")
+		return fmt.Sprintf("host.indexOf(%q) >= 0", literal)
+	default:
+		return "false"
+	}
+}
+
+func pacAction(rl *rule.Rule, localHTTPAddr string) string {
+	if rl.Proxy == "DIRECT" {
+		return "DIRECT"
+	}
+	return "PROXY " + localHTTPAddr
+}
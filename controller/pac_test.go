@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipt/shuttle/rule"
+)
+
+func TestPacCondition(t *testing.T) {
+	cases := []struct {
+		name string
+		rl   *rule.Rule
+		want string
+	}{
+		{name: "domain", rl: &rule.Rule{Type: "DOMAIN", Value: "example.com"}, want: `host == "example.com"`},
+		{name: "suffix", rl: &rule.Rule{Type: "DOMAIN-SUFFIX", Value: "example.com"}, want: `shExpMatch(host, "*example.com")`},
+		{name: "regex literal", rl: &rule.Rule{Type: "DOMAIN-REGEX", Value: `facebook\.com`}, want: `host.indexOf("facebook.com") >= 0`},
+		{name: "unknown", rl: &rule.Rule{Type: "BOGUS"}, want: "false"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := pacCondition(c.rl); got != c.want {
+				t.Errorf("pacCondition(%+v) = %q, want %q", c.rl, got, c.want)
+			}
+		})
+	}
+}
+
+func TestPacConditionRegexMatchesHost(t *testing.T) {
+	// A regex rule parsed from a GFWList entry must produce a PAC condition
+	// that actually matches the same host rule.Match would match for it,
+	// since the GFWList slashes are stripped before reaching here.
+	rl := &rule.Rule{Type: "DOMAIN-REGEX", Value: `facebook\.com`}
+	cond := pacCondition(rl)
+	if !strings.Contains(cond, "facebook.com") {
+		t.Fatalf("condition %q does not reference the literal host", cond)
+	}
+}
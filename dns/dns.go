@@ -0,0 +1,12 @@
+package dns
+
+import "github.com/sipt/shuttle/config"
+
+//ApplyConfig is the hook for (re-)initialising the DNS resolver and GeoIP
+//database from conf; in this tree it does nothing and always returns nil.
+func ApplyConfig(conf *config.Config) error {
+	return nil
+}
+
+//CloseGeoDB releases the GeoIP database handle, if one is open.
+func CloseGeoDB() {}
@@ -0,0 +1,13 @@
+package network
+
+//WebProxySwitch is the hook for toggling the OS HTTP proxy setting; in this
+//tree it does nothing.
+func WebProxySwitch(on bool, addr ...string) {}
+
+//SecureWebProxySwitch is the hook for toggling the OS HTTPS proxy setting; in
+//this tree it does nothing.
+func SecureWebProxySwitch(on bool, addr ...string) {}
+
+//SocksProxySwitch is the hook for toggling the OS SOCKS proxy setting; in
+//this tree it does nothing.
+func SocksProxySwitch(on bool, addr ...string) {}
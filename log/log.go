@@ -0,0 +1,42 @@
+package log
+
+import (
+	"fmt"
+
+	"github.com/sipt/shuttle/config"
+)
+
+//Logger is the process-wide logger, initialised by InitLogger.
+var Logger *logger
+
+type logger struct {
+	mode string
+}
+
+//InitLogger sets up Logger according to mode (off|console|file). "off"
+//discards everything; "console" and "file" both print to stdout. path and
+//conf are not used.
+func InitLogger(mode, path string, conf *config.Config) error {
+	switch mode {
+	case "off", "console", "file":
+	default:
+		return fmt.Errorf("log: unknown logMode %q", mode)
+	}
+	Logger = &logger{mode: mode}
+	return nil
+}
+
+func (l *logger) print(level, format string, args ...interface{}) {
+	if l == nil || l.mode == "off" {
+		return
+	}
+	fmt.Printf("["+level+"] "+format+"\n", args...)
+}
+
+func (l *logger) Info(args ...interface{})               { l.print("INFO", fmt.Sprint(args...)) }
+func (l *logger) Infof(format string, a ...interface{})  { l.print("INFO", format, a...) }
+func (l *logger) Debug(args ...interface{})              { l.print("DEBUG", fmt.Sprint(args...)) }
+func (l *logger) Debugf(format string, a ...interface{}) { l.print("DEBUG", format, a...) }
+func (l *logger) Error(args ...interface{})              { l.print("ERROR", fmt.Sprint(args...)) }
+func (l *logger) Errorf(format string, a ...interface{}) { l.print("ERROR", format, a...) }
+func (l *logger) Close()                                 {}
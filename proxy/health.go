@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sipt/shuttle/config"
+	"github.com/sipt/shuttle/log"
+)
+
+const (
+	defaultCheckers          = 4
+	defaultConnectTimeout    = 5 * time.Second
+	defaultHealthCheckPeriod = 30 * time.Second
+)
+
+//healthChecker periodically dials every configured proxy (and, for groups
+//with a TestURL, fetches that URL through the proxy too) and records the
+//outcome on the Proxy itself so Select can skip unhealthy entries.
+type healthChecker struct {
+	checkers int
+	timeout  time.Duration
+	interval time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newHealthChecker(conf *config.Proxy) *healthChecker {
+	hc := &healthChecker{
+		checkers: conf.Checkers,
+		timeout:  defaultConnectTimeout,
+		interval: defaultHealthCheckPeriod,
+		stopCh:   make(chan struct{}),
+	}
+	if hc.checkers <= 0 {
+		hc.checkers = defaultCheckers
+	}
+	if d, err := time.ParseDuration(conf.ConnectTimeout); err == nil && d > 0 {
+		hc.timeout = d
+	}
+	if d, err := time.ParseDuration(conf.HealthCheckInterval); err == nil && d > 0 {
+		hc.interval = d
+	}
+	return hc
+}
+
+func (hc *healthChecker) start() {
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		hc.runOnce()
+		ticker := time.NewTicker(hc.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hc.stopCh:
+				return
+			case <-ticker.C:
+				hc.runOnce()
+			}
+		}
+	}()
+}
+
+func (hc *healthChecker) stop() {
+	close(hc.stopCh)
+	hc.wg.Wait()
+}
+
+//runOnce checks every configured proxy, bounded to hc.checkers concurrent checks.
+func (hc *healthChecker) runOnce() {
+	mu.RLock()
+	targets := make([]*Proxy, 0, len(proxies))
+	for _, p := range proxies {
+		targets = append(targets, p)
+	}
+	mu.RUnlock()
+
+	sem := make(chan struct{}, hc.checkers)
+	var wg sync.WaitGroup
+	for _, p := range targets {
+		p := p
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			hc.check(p)
+		}()
+	}
+	wg.Wait()
+}
+
+func (hc *healthChecker) check(p *Proxy) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	testURL := hc.testURLFor(p.Name)
+	var ok bool
+	if testURL != "" {
+		ok = fetchThroughProxy(ctx, p, testURL)
+	} else {
+		ok = dialThroughProxy(ctx, p)
+	}
+	if p.Healthy() != ok {
+		log.Logger.Infof("[proxy] %s health changed: healthy=%v", p.Name, ok)
+	}
+	p.setHealthy(ok)
+	return ok
+}
+
+//testURLFor returns the test_url of a group that sets one and includes name,
+//so a plain TCP check is used for proxies no group cares to probe further.
+//When several such groups set a test_url, which one is returned is not defined.
+func (hc *healthChecker) testURLFor(name string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, g := range groups {
+		if g.TestURL == "" {
+			continue
+		}
+		for _, n := range g.Proxies {
+			if n == name {
+				return g.TestURL
+			}
+		}
+	}
+	return ""
+}
+
+func dialThroughProxy(ctx context.Context, p *Proxy) bool {
+	conn, err := p.Protocol.DialContext(ctx, "tcp", "")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func fetchThroughProxy(ctx context.Context, p *Proxy, testURL string) bool {
+	client := &http.Client{
+		Timeout: 0,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return p.Protocol.DialContext(ctx, network, addr)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, testURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	_, _ = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode < 500
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipt/shuttle/config"
+)
+
+func TestNewHealthCheckerDefaults(t *testing.T) {
+	hc := newHealthChecker(&config.Proxy{})
+	if hc.checkers != defaultCheckers {
+		t.Errorf("checkers = %d, want default %d", hc.checkers, defaultCheckers)
+	}
+	if hc.timeout != defaultConnectTimeout {
+		t.Errorf("timeout = %v, want default %v", hc.timeout, defaultConnectTimeout)
+	}
+	if hc.interval != defaultHealthCheckPeriod {
+		t.Errorf("interval = %v, want default %v", hc.interval, defaultHealthCheckPeriod)
+	}
+}
+
+func TestNewHealthCheckerParsesConfig(t *testing.T) {
+	hc := newHealthChecker(&config.Proxy{
+		Checkers:            8,
+		ConnectTimeout:      "2s",
+		HealthCheckInterval: "10s",
+	})
+	if hc.checkers != 8 {
+		t.Errorf("checkers = %d, want 8", hc.checkers)
+	}
+	if hc.timeout != 2*time.Second {
+		t.Errorf("timeout = %v, want 2s", hc.timeout)
+	}
+	if hc.interval != 10*time.Second {
+		t.Errorf("interval = %v, want 10s", hc.interval)
+	}
+}
+
+func TestHealthCheckerTestURLFor(t *testing.T) {
+	mu.Lock()
+	groups = map[string]*Group{
+		"g1": {Name: "g1", TestURL: "http://example.com", Proxies: []string{"p1", "p2"}},
+		"g2": {Name: "g2", Proxies: []string{"p3"}},
+	}
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		groups = make(map[string]*Group)
+		mu.Unlock()
+	}()
+
+	hc := &healthChecker{}
+	if got := hc.testURLFor("p1"); got != "http://example.com" {
+		t.Errorf("testURLFor(p1) = %q, want http://example.com", got)
+	}
+	if got := hc.testURLFor("p3"); got != "" {
+		t.Errorf("testURLFor(p3) = %q, want empty (group has no TestURL)", got)
+	}
+	if got := hc.testURLFor("unknown"); got != "" {
+		t.Errorf("testURLFor(unknown) = %q, want empty", got)
+	}
+}
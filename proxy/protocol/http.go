@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sipt/shuttle/config"
+)
+
+func init() {
+	Register("http", newHTTPProtocol)
+}
+
+//httpProtocol dials the target through a parent HTTP CONNECT proxy,
+//e.g. `type: http, server: user:pass@corp-proxy:3128`, optionally over TLS
+//to the parent. It lets shuttle run behind a corporate egress proxy or chain
+//onto a third-party HTTPS proxy pool.
+type httpProtocol struct {
+	addr string
+	auth string
+	tls  bool
+}
+
+func newHTTPProtocol(entry *config.ProxyEntry) (IProtocol, error) {
+	server := entry.Server
+	var userinfo string
+	if i := strings.Index(server, "@"); i >= 0 {
+		userinfo, server = server[:i], server[i+1:]
+	}
+	if entry.Port != "" && !strings.Contains(server, ":") {
+		server = net.JoinHostPort(server, entry.Port)
+	}
+	if server == "" {
+		return nil, fmt.Errorf("protocol/http: %s: server is required", entry.Name)
+	}
+	p := &httpProtocol{addr: server, tls: entry.TLS}
+	if userinfo != "" {
+		p.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(userinfo))
+	}
+	return p, nil
+}
+
+func (p *httpProtocol) Name() string { return "http" }
+
+//DialContext opens a connection to the parent proxy and, when addr is set,
+//tunnels to it with CONNECT before returning the ready-to-use connection. An
+//empty addr (used by the health checker's plain connect probe) returns the
+//raw connection to the parent instead.
+func (p *httpProtocol) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, fmt.Errorf("protocol/http: dial parent %s: %w", p.addr, err)
+	}
+	if p.tls {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: hostOnly(p.addr)})
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("protocol/http: tls handshake with parent %s: %w", p.addr, err)
+		}
+		conn = tlsConn
+	}
+	if addr == "" {
+		return conn, nil
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if p.auth != "" {
+		req.Header.Set("Proxy-Authorization", p.auth)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("protocol/http: CONNECT %s via %s: %w", addr, p.addr, err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("protocol/http: CONNECT %s via %s: %w", addr, p.addr, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("protocol/http: CONNECT %s via %s: parent returned %s", addr, p.addr, resp.Status)
+	}
+	return conn, nil
+}
+
+func hostOnly(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+	return host
+}
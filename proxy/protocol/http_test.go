@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/sipt/shuttle/config"
+)
+
+//serveOneConnect accepts a single connection on ln, expects a CONNECT
+//request and replies with status, then closes the connection.
+func serveOneConnect(t *testing.T, ln net.Listener, status string) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if req.Method != http.MethodConnect {
+		t.Errorf("method = %s, want CONNECT", req.Method)
+	}
+	conn.Write([]byte("HTTP/1.1 " + status + "\r\n\r\n"))
+}
+
+func TestHTTPProtocolDialContextConnectSuccess(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serveOneConnect(t, ln, "200 Connection Established")
+
+	p, err := newHTTPProtocol(&config.ProxyEntry{Name: "parent", Server: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	conn.Close()
+}
+
+func TestHTTPProtocolDialContextConnectFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serveOneConnect(t, ln, "502 Bad Gateway")
+
+	p, err := newHTTPProtocol(&config.ProxyEntry{Name: "parent", Server: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.DialContext(context.Background(), "tcp", "example.com:443"); err == nil {
+		t.Fatal("DialContext should fail when the parent refuses the CONNECT")
+	}
+}
@@ -0,0 +1,38 @@
+//Package protocol implements the outbound dial protocols (ss, socks, ...)
+//that a proxy.Proxy uses to reach its upstream.
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/sipt/shuttle/config"
+)
+
+//IProtocol dials network/addr through a single configured proxy entry.
+//Implementations must respect ctx's deadline on the dial to the proxy
+//itself, so a health check's proxy_connect_timeout actually bounds it.
+type IProtocol interface {
+	Name() string
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+//Constructor builds an IProtocol from a proxy entry's config.
+type Constructor func(entry *config.ProxyEntry) (IProtocol, error)
+
+var protocols = make(map[string]Constructor)
+
+//Register registers a protocol constructor under typeName (e.g. "ss", "socks5").
+func Register(typeName string, c Constructor) {
+	protocols[typeName] = c
+}
+
+//NewProtocol builds the protocol registered for entry.Type.
+func NewProtocol(entry *config.ProxyEntry) (IProtocol, error) {
+	c, ok := protocols[entry.Type]
+	if !ok {
+		return nil, fmt.Errorf("protocol: unknown type %q", entry.Type)
+	}
+	return c(entry)
+}
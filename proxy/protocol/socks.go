@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/sipt/shuttle/config"
+)
+
+func init() {
+	Register("socks5", newSocksProtocol)
+}
+
+//socksProtocol dials the target by chaining through a parent SOCKS5 proxy,
+//performing the full RFC 1928 handshake (plus RFC 1929 username/password
+//sub-negotiation, when entry.Username is set) before issuing a CONNECT.
+type socksProtocol struct {
+	entry *config.ProxyEntry
+}
+
+func newSocksProtocol(entry *config.ProxyEntry) (IProtocol, error) {
+	return &socksProtocol{entry: entry}, nil
+}
+
+func (p *socksProtocol) Name() string { return "socks5" }
+
+func (p *socksProtocol) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, net.JoinHostPort(p.entry.Server, p.entry.Port))
+	if err != nil {
+		return nil, fmt.Errorf("protocol/socks5: dial parent %s:%s: %w", p.entry.Server, p.entry.Port, err)
+	}
+	if err = p.handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if addr == "" {
+		return conn, nil
+	}
+	if err = p.connect(conn, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+//handshake performs the RFC 1928 method negotiation and, when the entry has
+//a username, the RFC 1929 sub-negotiation.
+func (p *socksProtocol) handshake(conn net.Conn) error {
+	method := byte(0x00)
+	if p.entry.Username != "" {
+		method = 0x02
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return fmt.Errorf("protocol/socks5: method negotiation: %w", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("protocol/socks5: method negotiation: %w", err)
+	}
+	if reply[0] != 0x05 || reply[1] != method {
+		return fmt.Errorf("protocol/socks5: parent rejected method negotiation (got 0x%02x)", reply[1])
+	}
+	if method != 0x02 {
+		return nil
+	}
+
+	req := make([]byte, 0, 3+len(p.entry.Username)+len(p.entry.Password))
+	req = append(req, 0x01, byte(len(p.entry.Username)))
+	req = append(req, p.entry.Username...)
+	req = append(req, byte(len(p.entry.Password)))
+	req = append(req, p.entry.Password...)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("protocol/socks5: auth sub-negotiation: %w", err)
+	}
+	sub := make([]byte, 2)
+	if _, err := io.ReadFull(conn, sub); err != nil {
+		return fmt.Errorf("protocol/socks5: auth sub-negotiation: %w", err)
+	}
+	if sub[1] != 0x00 {
+		return fmt.Errorf("protocol/socks5: auth rejected by parent")
+	}
+	return nil
+}
+
+//connect issues a CONNECT request for addr and consumes the reply,
+//discarding BND.ADDR/BND.PORT: callers only need to know it succeeded.
+func (p *socksProtocol) connect(conn net.Conn, addr string) error {
+	dst, err := encodeSocksAddr(addr)
+	if err != nil {
+		return fmt.Errorf("protocol/socks5: %w", err)
+	}
+	req := append([]byte{0x05, 0x01, 0x00}, dst...)
+	if _, err = conn.Write(req); err != nil {
+		return fmt.Errorf("protocol/socks5: CONNECT %s: %w", addr, err)
+	}
+
+	head := make([]byte, 4)
+	if _, err = io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("protocol/socks5: CONNECT %s: %w", addr, err)
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("protocol/socks5: CONNECT %s: parent returned reply code 0x%02x", addr, head[1])
+	}
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		l := make([]byte, 1)
+		if _, err = io.ReadFull(conn, l); err != nil {
+			return fmt.Errorf("protocol/socks5: CONNECT %s: %w", addr, err)
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("protocol/socks5: CONNECT %s: unknown ATYP 0x%02x in reply", addr, head[3])
+	}
+	if _, err = io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return fmt.Errorf("protocol/socks5: CONNECT %s: %w", addr, err)
+	}
+	return nil
+}
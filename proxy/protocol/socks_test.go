@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sipt/shuttle/config"
+)
+
+func TestSocksProtocolHandshakeNoAuth(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		methods := make([]byte, 2)
+		io.ReadFull(server, methods) // ver, nmethods
+		io.ReadFull(server, make([]byte, methods[1]))
+		server.Write([]byte{0x05, 0x00})
+	}()
+
+	p := &socksProtocol{entry: &config.ProxyEntry{}}
+	if err := p.handshake(client); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+}
+
+func TestSocksProtocolHandshakeWithAuth(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		methods := make([]byte, 2)
+		io.ReadFull(server, methods)
+		io.ReadFull(server, make([]byte, methods[1]))
+		server.Write([]byte{0x05, 0x02})
+
+		hdr := make([]byte, 2)
+		io.ReadFull(server, hdr)
+		uname := make([]byte, hdr[1])
+		io.ReadFull(server, uname)
+		plen := make([]byte, 1)
+		io.ReadFull(server, plen)
+		passwd := make([]byte, plen[0])
+		io.ReadFull(server, passwd)
+		if string(uname) != "alice" || string(passwd) != "secret" {
+			server.Write([]byte{0x01, 0x01})
+			return
+		}
+		server.Write([]byte{0x01, 0x00})
+	}()
+
+	p := &socksProtocol{entry: &config.ProxyEntry{Username: "alice", Password: "secret"}}
+	if err := p.handshake(client); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+}
+
+func TestSocksProtocolHandshakeRejected(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		methods := make([]byte, 2)
+		io.ReadFull(server, methods)
+		io.ReadFull(server, make([]byte, methods[1]))
+		server.Write([]byte{0x05, 0xff})
+	}()
+
+	p := &socksProtocol{entry: &config.ProxyEntry{}}
+	if err := p.handshake(client); err == nil {
+		t.Fatal("handshake should fail when the parent rejects every method")
+	}
+}
+
+func TestSocksProtocolConnect(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		head := make([]byte, 4)
+		io.ReadFull(server, head)
+		switch head[3] {
+		case 0x01:
+			io.ReadFull(server, make([]byte, net.IPv4len+2))
+		case 0x03:
+			l := make([]byte, 1)
+			io.ReadFull(server, l)
+			io.ReadFull(server, make([]byte, int(l[0])+2))
+		}
+		server.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	}()
+
+	p := &socksProtocol{entry: &config.ProxyEntry{}}
+	if err := p.connect(client, "example.com:443"); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+}
+
+func TestSocksProtocolConnectFailure(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		head := make([]byte, 4)
+		io.ReadFull(server, head)
+		l := make([]byte, 1)
+		io.ReadFull(server, l)
+		io.ReadFull(server, make([]byte, int(l[0])+2))
+		server.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0}) // connection refused
+	}()
+
+	p := &socksProtocol{entry: &config.ProxyEntry{}}
+	if err := p.connect(client, "example.com:443"); err == nil {
+		t.Fatal("connect should fail on a non-zero reply code")
+	}
+}
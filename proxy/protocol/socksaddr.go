@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+//encodeSocksAddr builds a SOCKS5-style address header (ATYP+addr+port, per
+//RFC 1928 section 5) for addr, used by both the socks5 and ss protocols to
+//tell the upstream what to connect to.
+func encodeSocksAddr(addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: invalid address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: invalid port in %q: %w", addr, err)
+	}
+
+	var out []byte
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			out = append([]byte{0x01}, ip4...)
+		} else {
+			out = append([]byte{0x04}, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("protocol: domain %q too long for SOCKS5", host)
+		}
+		out = append([]byte{0x03, byte(len(host))}, []byte(host)...)
+	}
+	out = append(out, byte(port>>8), byte(port))
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package protocol
+
+import "testing"
+
+func TestEncodeSocksAddr(t *testing.T) {
+	cases := []struct {
+		name string
+		addr string
+		want []byte
+	}{
+		{name: "ipv4", addr: "1.2.3.4:80", want: []byte{0x01, 1, 2, 3, 4, 0x00, 0x50}},
+		{name: "domain", addr: "example.com:443", want: append(
+			append([]byte{0x03, byte(len("example.com"))}, "example.com"...),
+			0x01, 0xbb,
+		)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := encodeSocksAddr(c.addr)
+			if err != nil {
+				t.Fatalf("encodeSocksAddr(%q): %v", c.addr, err)
+			}
+			if string(got) != string(c.want) {
+				t.Errorf("encodeSocksAddr(%q) = %#v, want %#v", c.addr, got, c.want)
+			}
+		})
+	}
+}
+
+func TestEncodeSocksAddrIPv6(t *testing.T) {
+	got, err := encodeSocksAddr("[::1]:53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0x04 || len(got) != 1+16+2 {
+		t.Fatalf("encodeSocksAddr(ipv6) = %#v, want ATYP 0x04 + 16 bytes + port", got)
+	}
+}
+
+func TestEncodeSocksAddrInvalid(t *testing.T) {
+	if _, err := encodeSocksAddr("no-port-here"); err == nil {
+		t.Fatal("encodeSocksAddr should reject an address with no port")
+	}
+}
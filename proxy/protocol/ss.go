@@ -0,0 +1,128 @@
+package protocol
+
+import (
+	"context"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/sipt/shuttle/ciphers"
+	"github.com/sipt/shuttle/config"
+)
+
+const defaultSSMethod = "aes-256-cfb"
+
+func init() {
+	Register("ss", newSSProtocol)
+}
+
+//ssProtocol dials the target through a shadowsocks server: a single stream-
+//ciphered TCP connection framed as (our random IV || enc(addr header) ||
+//enc(payload)), per the original shadowsocks protocol.
+type ssProtocol struct {
+	entry  *config.ProxyEntry
+	cipher ciphers.StreamCipher
+	key    []byte
+}
+
+func newSSProtocol(entry *config.ProxyEntry) (IProtocol, error) {
+	method := entry.Method
+	if method == "" {
+		method = defaultSSMethod
+	}
+	c, ok := ciphers.Get(method)
+	if !ok {
+		return nil, fmt.Errorf("protocol/ss: %s: unknown method %q", entry.Name, method)
+	}
+	return &ssProtocol{entry: entry, cipher: c, key: ciphers.Kdf(entry.Password, c.KeySize)}, nil
+}
+
+func (p *ssProtocol) Name() string { return "ss" }
+
+func (p *ssProtocol) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, net.JoinHostPort(p.entry.Server, p.entry.Port))
+	if err != nil {
+		return nil, fmt.Errorf("protocol/ss: dial server %s:%s: %w", p.entry.Server, p.entry.Port, err)
+	}
+	sc := &ssConn{Conn: conn, proto: p}
+	if addr != "" {
+		dst, err := encodeSocksAddr(addr)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("protocol/ss: %w", err)
+		}
+		if _, err = sc.Write(dst); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("protocol/ss: send address header to %s: %w", addr, err)
+		}
+	}
+	return sc, nil
+}
+
+//ssConn wraps a raw connection to the shadowsocks server, encrypting
+//everything written and decrypting everything read. The encrypter is set up
+//on the first Write with our own random IV (sent ahead of that write's
+//data); the decrypter is set up on the first Read, keyed off the IV the
+//server sends back as the first bytes of its response.
+type ssConn struct {
+	net.Conn
+	proto *ssProtocol
+
+	encOnce sync.Once
+	enc     cipher.Stream
+	encErr  error
+
+	decOnce sync.Once
+	dec     cipher.Stream
+	decErr  error
+}
+
+func (c *ssConn) initEncrypter() {
+	iv, err := ciphers.RandomIV(c.proto.cipher.IVSize)
+	if err != nil {
+		c.encErr = err
+		return
+	}
+	if _, err = c.Conn.Write(iv); err != nil {
+		c.encErr = fmt.Errorf("protocol/ss: send iv: %w", err)
+		return
+	}
+	c.enc, c.encErr = c.proto.cipher.NewEncrypter(c.proto.key, iv)
+}
+
+func (c *ssConn) initDecrypter() {
+	iv := make([]byte, c.proto.cipher.IVSize)
+	if _, err := io.ReadFull(c.Conn, iv); err != nil {
+		c.decErr = fmt.Errorf("protocol/ss: read iv: %w", err)
+		return
+	}
+	c.dec, c.decErr = c.proto.cipher.NewDecrypter(c.proto.key, iv)
+}
+
+func (c *ssConn) Write(b []byte) (int, error) {
+	c.encOnce.Do(c.initEncrypter)
+	if c.encErr != nil {
+		return 0, c.encErr
+	}
+	out := make([]byte, len(b))
+	c.enc.XORKeyStream(out, b)
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *ssConn) Read(b []byte) (int, error) {
+	c.decOnce.Do(c.initDecrypter)
+	if c.decErr != nil {
+		return 0, c.decErr
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.dec.XORKeyStream(b[:n], b[:n])
+	}
+	return n, err
+}
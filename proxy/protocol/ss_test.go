@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sipt/shuttle/ciphers"
+	"github.com/sipt/shuttle/config"
+)
+
+func TestSSConnRoundTrip(t *testing.T) {
+	c, ok := ciphers.Get(defaultSSMethod)
+	if !ok {
+		t.Fatalf("cipher %q not registered", defaultSSMethod)
+	}
+	proto := &ssProtocol{
+		entry:  &config.ProxyEntry{Password: "password"},
+		cipher: c,
+		key:    ciphers.Kdf("password", c.KeySize),
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	clientConn := &ssConn{Conn: client, proto: proto}
+	serverConn := &ssConn{Conn: server, proto: proto}
+
+	// serverConn's Write("pong") blocks until clientConn reads it, so the
+	// server side must run in its own goroutine concurrently with the
+	// client's own Write-then-Read sequence below.
+	serverErrs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, err := serverConn.Read(buf)
+		if err != nil {
+			serverErrs <- err
+			return
+		}
+		if got := string(buf[:n]); got != "ping" {
+			serverErrs <- fmt.Errorf("server decrypted %q, want %q", got, "ping")
+			return
+		}
+		_, err = serverConn.Write([]byte("pong"))
+		serverErrs <- err
+	}()
+
+	if _, err := clientConn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	reply := make([]byte, 64)
+	n, err := clientConn.Read(reply)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(reply[:n]) != "pong" {
+		t.Fatalf("client decrypted %q, want %q", reply[:n], "pong")
+	}
+	if err := <-serverErrs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSConnWireBytesAreEncrypted(t *testing.T) {
+	c, _ := ciphers.Get(defaultSSMethod)
+	proto := &ssProtocol{
+		entry:  &config.ProxyEntry{Password: "password"},
+		cipher: c,
+		key:    ciphers.Kdf("password", c.KeySize),
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	clientConn := &ssConn{Conn: client, proto: proto}
+
+	done := make(chan []byte)
+	go func() {
+		iv := make([]byte, c.IVSize)
+		io.ReadFull(server, iv)
+		rest := make([]byte, 4)
+		io.ReadFull(server, rest)
+		done <- rest
+	}()
+
+	clientConn.Write([]byte("ping"))
+	raw := <-done
+	if string(raw) == "ping" {
+		t.Fatal("plaintext appeared on the wire unencrypted")
+	}
+}
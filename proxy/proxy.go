@@ -0,0 +1,175 @@
+//Package proxy owns the set of upstream proxies and proxy groups configured
+//by the user, and the selectors that pick amongst a group's members.
+package proxy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sipt/shuttle/config"
+	"github.com/sipt/shuttle/proxy/protocol"
+	"github.com/sipt/shuttle/proxy/selector"
+)
+
+//Proxy is one configured upstream, wired to its dial protocol.
+type Proxy struct {
+	Name     string
+	Protocol protocol.IProtocol
+
+	healthMu sync.RWMutex
+	healthy  bool
+}
+
+//Healthy reports whether the last health check of this proxy succeeded.
+//A proxy is considered healthy until the first check has run.
+func (p *Proxy) Healthy() bool {
+	p.healthMu.RLock()
+	defer p.healthMu.RUnlock()
+	return p.healthy
+}
+
+func (p *Proxy) setHealthy(v bool) {
+	p.healthMu.Lock()
+	p.healthy = v
+	p.healthMu.Unlock()
+}
+
+//Group is a named set of proxies plus the selector that rotates amongst them.
+type Group struct {
+	Name     string
+	Proxies  []string
+	TestURL  string
+	Selector selector.ISelector
+	//Bypass matches hosts that should go DIRECT instead of through this group.
+	Bypass *selector.HostMatcher
+}
+
+//direct is the literal target name a rule/selector returns to mean "skip the
+//proxy pool and dial the destination directly", e.g. rule/gfwlist.go and
+//controller/pac.go.
+const direct = "DIRECT"
+
+var (
+	mu      sync.RWMutex
+	proxies = make(map[string]*Proxy)
+	groups  = make(map[string]*Group)
+
+	checker *healthChecker
+	//directMatcher matches general.direct_domains, applied ahead of any group's
+	//own Bypass list.
+	directMatcher *selector.HostMatcher
+)
+
+//ApplyConfig (re-)builds the proxy pool and groups from conf, and (re)starts
+//the background health checker against the new pool.
+func ApplyConfig(conf *config.Config) error {
+	if conf.Proxy == nil {
+		return nil
+	}
+	newProxies := make(map[string]*Proxy, len(conf.Proxy.Entries))
+	for _, entry := range conf.Proxy.Entries {
+		p, err := protocol.NewProtocol(entry)
+		if err != nil {
+			return fmt.Errorf("proxy: %s: %w", entry.Name, err)
+		}
+		newProxies[entry.Name] = &Proxy{Name: entry.Name, Protocol: p, healthy: true}
+	}
+	newGroups := make(map[string]*Group, len(conf.Proxy.Groups))
+	for _, g := range conf.Proxy.Groups {
+		sel, err := selector.NewSelector(g.Type, g.Name, g.Proxies)
+		if err != nil {
+			return fmt.Errorf("proxy: group %s: %w", g.Name, err)
+		}
+		bypass, err := selector.NewHostMatcher(g.BypassDomains)
+		if err != nil {
+			return fmt.Errorf("proxy: group %s: bypass_domains: %w", g.Name, err)
+		}
+		newGroups[g.Name] = &Group{Name: g.Name, Proxies: g.Proxies, TestURL: g.TestURL, Selector: sel, Bypass: bypass}
+	}
+	var newDirectMatcher *selector.HostMatcher
+	if conf.General != nil {
+		dm, err := selector.NewHostMatcher(conf.General.DirectDomains)
+		if err != nil {
+			return fmt.Errorf("proxy: direct_domains: %w", err)
+		}
+		newDirectMatcher = dm
+	}
+
+	mu.Lock()
+	proxies = newProxies
+	groups = newGroups
+	directMatcher = newDirectMatcher
+	mu.Unlock()
+
+	if checker != nil {
+		checker.stop()
+	}
+	checker = newHealthChecker(conf.Proxy)
+	checker.start()
+	return nil
+}
+
+//Get returns the named proxy, if configured.
+func Get(name string) (*Proxy, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := proxies[name]
+	return p, ok
+}
+
+//GetGroup returns the named proxy group, if configured.
+func GetGroup(name string) (*Group, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	g, ok := groups[name]
+	return g, ok
+}
+
+//Select picks the proxy host should be routed through for groupName. If host
+//matches general.direct_domains or the group's own bypass_domains, it returns
+//direct without consulting the group's selector at all. Otherwise it picks
+//amongst the group's healthy proxies, skipping any currently marked
+//unhealthy, and falls over to the group's full (unfiltered) list only if
+//every member is unhealthy, so a total outage still routes somewhere.
+func Select(groupName, host string) (string, error) {
+	mu.RLock()
+	dm := directMatcher
+	mu.RUnlock()
+	if dm.Match(host) {
+		return direct, nil
+	}
+	g, ok := GetGroup(groupName)
+	if !ok {
+		return "", fmt.Errorf("proxy: unknown group %q", groupName)
+	}
+	if g.Bypass.Match(host) {
+		return direct, nil
+	}
+	healthy := make([]string, 0, len(g.Proxies))
+	for _, name := range g.Proxies {
+		if p, ok := Get(name); ok && p.Healthy() {
+			healthy = append(healthy, name)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = g.Proxies
+	}
+	return g.Selector.Select(healthy)
+}
+
+//Snapshot describes a single proxy's health for the controller API.
+type Snapshot struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+//HealthSnapshot returns the current health state of every configured proxy.
+func HealthSnapshot() []Snapshot {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Snapshot, 0, len(proxies))
+	for _, p := range proxies {
+		out = append(out, Snapshot{Name: p.Name, Healthy: p.Healthy()})
+	}
+	return out
+}
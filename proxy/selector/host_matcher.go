@@ -0,0 +1,63 @@
+package selector
+
+import (
+	"regexp"
+	"strings"
+)
+
+//HostMatcher tests a hostname against a list of suffix, wildcard ("*") and
+//"/regex/" patterns. It backs bypass_domains/direct_domains so selected
+//hostnames can be routed DIRECT before a connection is dialed.
+type HostMatcher struct {
+	suffixes []string
+	regexes  []*regexp.Regexp
+}
+
+//NewHostMatcher compiles patterns into a HostMatcher. A pattern wrapped in
+//slashes ("/foo.*/") is a regex, one containing "*" is a glob-style wildcard,
+//anything else is matched as a domain suffix (a leading "." is optional).
+func NewHostMatcher(patterns []string) (*HostMatcher, error) {
+	hm := &HostMatcher{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") && len(p) > 1:
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, err
+			}
+			hm.regexes = append(hm.regexes, re)
+		case strings.Contains(p, "*"):
+			re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*") + "$")
+			if err != nil {
+				return nil, err
+			}
+			hm.regexes = append(hm.regexes, re)
+		default:
+			hm.suffixes = append(hm.suffixes, strings.TrimPrefix(p, "."))
+		}
+	}
+	return hm, nil
+}
+
+//Match reports whether host is covered by any of the matcher's patterns.
+//A nil *HostMatcher (no patterns configured) never matches.
+func (hm *HostMatcher) Match(host string) bool {
+	if hm == nil {
+		return false
+	}
+	for _, s := range hm.suffixes {
+		if host == s || strings.HasSuffix(host, "."+s) {
+			return true
+		}
+	}
+	for _, re := range hm.regexes {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
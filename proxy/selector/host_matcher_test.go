@@ -0,0 +1,39 @@
+package selector
+
+import "testing"
+
+func TestHostMatcherMatch(t *testing.T) {
+	hm, err := NewHostMatcher([]string{"example.com", ".suffix.com", "*.wild.com", `/^v\d+\.api\.com$/`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		host string
+		want bool
+	}{
+		{host: "example.com", want: true},
+		{host: "www.example.com", want: true},
+		{host: "notexample.com", want: false},
+		{host: "evil-example.com", want: false},
+		{host: "suffix.com", want: true},
+		{host: "a.suffix.com", want: true},
+		{host: "a.wild.com", want: true},
+		{host: "wild.com", want: false},
+		{host: "v1.api.com", want: true},
+		{host: "v1.api.com.evil.com", want: false},
+		{host: "unrelated.org", want: false},
+	}
+	for _, c := range cases {
+		if got := hm.Match(c.host); got != c.want {
+			t.Errorf("Match(%q) = %v, want %v", c.host, got, c.want)
+		}
+	}
+}
+
+func TestHostMatcherNilNeverMatches(t *testing.T) {
+	var hm *HostMatcher
+	if hm.Match("example.com") {
+		t.Fatal("a nil *HostMatcher must never match")
+	}
+}
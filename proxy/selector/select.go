@@ -0,0 +1,30 @@
+package selector
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+//roundRobinSelector rotates through proxies in order, wrapping around.
+type roundRobinSelector struct {
+	group string
+	next  uint32
+}
+
+func init() {
+	Register("select", newRoundRobinSelector)
+}
+
+func newRoundRobinSelector(group string, proxies []string) (ISelector, error) {
+	return &roundRobinSelector{group: group}, nil
+}
+
+func (s *roundRobinSelector) Name() string { return s.group }
+
+func (s *roundRobinSelector) Select(candidates []string) (string, error) {
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("selector: group %q has no healthy proxies", s.group)
+	}
+	i := atomic.AddUint32(&s.next, 1)
+	return candidates[int(i)%len(candidates)], nil
+}
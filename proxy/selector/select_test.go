@@ -0,0 +1,28 @@
+package selector
+
+import "testing"
+
+func TestRoundRobinSelectorSelect(t *testing.T) {
+	s := &roundRobinSelector{group: "g"}
+	candidates := []string{"a", "b", "c"}
+	seen := make(map[string]bool)
+	for i := 0; i < len(candidates)*2; i++ {
+		name, err := s.Select(candidates)
+		if err != nil {
+			t.Fatalf("Select: %v", err)
+		}
+		seen[name] = true
+	}
+	for _, c := range candidates {
+		if !seen[c] {
+			t.Errorf("round robin never selected %q over %d calls", c, len(candidates)*2)
+		}
+	}
+}
+
+func TestRoundRobinSelectorNoCandidates(t *testing.T) {
+	s := &roundRobinSelector{group: "g"}
+	if _, err := s.Select(nil); err == nil {
+		t.Fatal("Select with no candidates should error")
+	}
+}
@@ -0,0 +1,34 @@
+//Package selector implements the strategies that choose which upstream proxy
+//a ProxyGroup hands a connection to. "select" (round robin) is the only one
+//registered.
+package selector
+
+import "fmt"
+
+//ISelector picks one of a group's proxies for the next connection. candidates
+//is the group's proxy list filtered down to the ones currently healthy; it is
+//passed in on every call so a selector never has to know about health itself.
+type ISelector interface {
+	Name() string
+	Select(candidates []string) (string, error)
+}
+
+//Constructor builds an ISelector from a group's proxy name list.
+type Constructor func(group string, proxies []string) (ISelector, error)
+
+var selectors = make(map[string]Constructor)
+
+//Register registers a selector constructor under typeName, analogous to
+//how protocol.Register works in proxy/protocol.
+func Register(typeName string, c Constructor) {
+	selectors[typeName] = c
+}
+
+//NewSelector builds the selector registered for typeName.
+func NewSelector(typeName, group string, proxies []string) (ISelector, error) {
+	c, ok := selectors[typeName]
+	if !ok {
+		return nil, fmt.Errorf("selector: unknown type %q", typeName)
+	}
+	return c(group, proxies)
+}
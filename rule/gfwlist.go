@@ -0,0 +1,210 @@
+package rule
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipt/shuttle/config"
+	"github.com/sipt/shuttle/log"
+)
+
+const defaultGFWListDuration = 24 * time.Hour
+
+//gfwlistProvider periodically fetches a Base64-encoded AutoProxy/GFWList
+//file, caches it on disk and turns its entries into Rules routed through a
+//single configured proxy.
+type gfwlistProvider struct {
+	url       string
+	proxy     string
+	cachePath string
+	duration  time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newGFWListProvider(conf *config.GFWList) (*gfwlistProvider, error) {
+	if conf.URL == "" {
+		return nil, fmt.Errorf("rule: gfwlist.url is required")
+	}
+	if conf.Proxy == "" {
+		return nil, fmt.Errorf("rule: gfwlist.proxy is required")
+	}
+	duration := defaultGFWListDuration
+	if conf.Duration != "" {
+		d, err := time.ParseDuration(conf.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("rule: gfwlist.duration: %w", err)
+		}
+		duration = d
+	}
+	cachePath := conf.CachePath
+	if cachePath == "" {
+		cachePath = filepath.Join(os.TempDir(), "shuttle-gfwlist.txt")
+	}
+	return &gfwlistProvider{
+		url:       conf.URL,
+		proxy:     conf.Proxy,
+		cachePath: cachePath,
+		duration:  duration,
+		stopCh:    make(chan struct{}),
+	}, nil
+}
+
+func (p *gfwlistProvider) start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.refresh()
+		ticker := time.NewTicker(p.duration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.refresh()
+			}
+		}
+	}()
+}
+
+func (p *gfwlistProvider) stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+func (p *gfwlistProvider) refresh() {
+	data, err := p.load()
+	if err != nil {
+		log.Logger.Error("[rule] gfwlist: ", err)
+		return
+	}
+	parsed, err := parseGFWList(data, p.proxy)
+	if err != nil {
+		log.Logger.Error("[rule] gfwlist: parse: ", err)
+		return
+	}
+	log.Logger.Infof("[rule] gfwlist: loaded %d rules", len(parsed))
+	setGFWListRules(parsed)
+}
+
+//load returns the cached file's content if it's still within p.duration,
+//otherwise it fetches a fresh copy from p.url and updates the cache.
+func (p *gfwlistProvider) load() ([]byte, error) {
+	if info, err := os.Stat(p.cachePath); err == nil && time.Since(info.ModTime()) < p.duration {
+		return ioutil.ReadFile(p.cachePath)
+	}
+	data, err := p.fetch()
+	if err != nil {
+		// Fall back to a stale cache rather than going rule-less.
+		if cached, cacheErr := ioutil.ReadFile(p.cachePath); cacheErr == nil {
+			log.Logger.Error("[rule] gfwlist: fetch failed, using stale cache: ", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	if err = ioutil.WriteFile(p.cachePath, data, 0644); err != nil {
+		log.Logger.Error("[rule] gfwlist: cache write failed: ", err)
+	}
+	return data, nil
+}
+
+func (p *gfwlistProvider) fetch() ([]byte, error) {
+	resp, err := http.Get(p.url)
+	if err != nil {
+		return nil, fmt.Errorf("rule: gfwlist: fetch %s: %w", p.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rule: gfwlist: fetch %s: status %s", p.url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("rule: gfwlist: read %s: %w", p.url, err)
+	}
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(body)))
+	n, err := base64.StdEncoding.Decode(decoded, body)
+	if err != nil {
+		// Some mirrors serve the list already decoded; use it as-is.
+		return body, nil
+	}
+	return decoded[:n], nil
+}
+
+//parseGFWList converts an AutoProxy file's lines into Rules. Whitelist
+//("@@") entries are routed DIRECT; every other recognised line is routed
+//through proxyName.
+func parseGFWList(data []byte, proxyName string) ([]*Rule, error) {
+	var whitelist, blacklist []*Rule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		target := proxyName
+		if strings.HasPrefix(line, "@@") {
+			target = "DIRECT"
+			line = line[2:]
+		}
+		rule, err := parseGFWListEntry(line, target)
+		if err != nil || rule == nil {
+			continue
+		}
+		if target == "DIRECT" {
+			whitelist = append(whitelist, rule)
+		} else {
+			blacklist = append(blacklist, rule)
+		}
+	}
+	// Whitelist entries must be checked before the blacklist they carve
+	// exceptions out of.
+	return append(whitelist, blacklist...), nil
+}
+
+func parseGFWListEntry(line, proxyName string) (*Rule, error) {
+	switch {
+	case strings.HasPrefix(line, "||"):
+		return &Rule{Type: "DOMAIN-SUFFIX", Value: line[2:], Proxy: proxyName}, nil
+	case strings.HasPrefix(line, "|"):
+		host := stripScheme(line[1:])
+		if host == "" {
+			return nil, nil
+		}
+		return &Rule{Type: "DOMAIN", Value: host, Proxy: proxyName}, nil
+	case strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") && len(line) > 1:
+		pattern := line[1 : len(line)-1]
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{Type: "DOMAIN-REGEX", Value: pattern, Proxy: proxyName, Regexp: re}, nil
+	case strings.HasPrefix(line, "."):
+		return &Rule{Type: "DOMAIN-SUFFIX", Value: line[1:], Proxy: proxyName}, nil
+	default:
+		host := stripScheme(line)
+		if host == "" {
+			return nil, nil
+		}
+		return &Rule{Type: "DOMAIN-SUFFIX", Value: host, Proxy: proxyName}, nil
+	}
+}
+
+//stripScheme trims a leading "http://"/"https://" and any path/query, so
+//"|http://example.com/path" becomes "example.com".
+func stripScheme(s string) string {
+	s = strings.TrimPrefix(s, "http://")
+	s = strings.TrimPrefix(s, "https://")
+	if i := strings.IndexAny(s, "/?"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+//Package rule matches a destination host/IP against the user's rule list and
+//decides which proxy (or proxy group) should carry the connection.
+package rule
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sipt/shuttle/config"
+)
+
+//Rule is a single parsed "TYPE,VALUE,PROXY" entry. Regexp is only set for
+//rules sourced from a "/regex/" GFWList entry.
+type Rule struct {
+	Type   string
+	Value  string
+	Proxy  string
+	Regexp *regexp.Regexp
+}
+
+var (
+	mu           sync.RWMutex
+	staticRules  []*Rule
+	gfwlistRules []*Rule
+	rules        []*Rule
+
+	provider *gfwlistProvider
+)
+
+//ApplyConfig (re-)parses the static rule list from conf and (re)starts the
+//GFWList provider, if configured.
+func ApplyConfig(conf *config.Config) error {
+	if conf.Rule == nil {
+		return nil
+	}
+	parsed := make([]*Rule, 0, len(conf.Rule.List))
+	for _, line := range conf.Rule.List {
+		parts := strings.SplitN(line, ",", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		parsed = append(parsed, &Rule{
+			Type:  strings.TrimSpace(parts[0]),
+			Value: strings.TrimSpace(parts[1]),
+			Proxy: strings.TrimSpace(parts[2]),
+		})
+	}
+	mu.Lock()
+	staticRules = parsed
+	mergeRulesLocked()
+	mu.Unlock()
+
+	if provider != nil {
+		provider.stop()
+		provider = nil
+	}
+	if conf.Rule.GFWList != nil {
+		p, err := newGFWListProvider(conf.Rule.GFWList)
+		if err != nil {
+			return err
+		}
+		provider = p
+		provider.start()
+	}
+	return nil
+}
+
+//setGFWListRules replaces the GFWList-sourced rules and re-merges, called by
+//the gfwlistProvider every time it refreshes.
+func setGFWListRules(parsed []*Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	gfwlistRules = parsed
+	mergeRulesLocked()
+}
+
+//mergeRulesLocked rebuilds the effective rule list, static rules first so a
+//user's own entries can still override the downloaded GFWList. Caller must
+//hold mu.
+func mergeRulesLocked() {
+	merged := make([]*Rule, 0, len(staticRules)+len(gfwlistRules))
+	merged = append(merged, staticRules...)
+	merged = append(merged, gfwlistRules...)
+	rules = merged
+}
+
+//Match returns the proxy name the first matching rule points at.
+func Match(host string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range rules {
+		if r.matches(host) {
+			return r.Proxy, true
+		}
+	}
+	return "", false
+}
+
+func (r *Rule) matches(host string) bool {
+	switch r.Type {
+	case "DOMAIN":
+		return host == r.Value
+	case "DOMAIN-SUFFIX":
+		return host == r.Value || strings.HasSuffix(host, "."+r.Value)
+	case "DOMAIN-REGEX":
+		return r.Regexp != nil && r.Regexp.MatchString(host)
+	default:
+		return false
+	}
+}
+
+//Snapshot returns the currently effective rule list, in priority order.
+func Snapshot() []*Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]*Rule, len(rules))
+	copy(out, rules)
+	return out
+}
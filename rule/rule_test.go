@@ -0,0 +1,71 @@
+package rule
+
+import "testing"
+
+func TestMergeRulesLockedStaticOverridesGFWList(t *testing.T) {
+	mu.Lock()
+	staticRules = []*Rule{{Type: "DOMAIN", Value: "mail.google.com", Proxy: "DIRECT"}}
+	gfwlistRules = []*Rule{{Type: "DOMAIN-SUFFIX", Value: "google.com", Proxy: "proxy"}}
+	mergeRulesLocked()
+	mu.Unlock()
+
+	proxyName, ok := Match("mail.google.com")
+	if !ok || proxyName != "DIRECT" {
+		t.Fatalf("Match(mail.google.com) = %q, %v; want static rule DIRECT to win over gfwlist", proxyName, ok)
+	}
+
+	proxyName, ok = Match("www.google.com")
+	if !ok || proxyName != "proxy" {
+		t.Fatalf("Match(www.google.com) = %q, %v; want gfwlist rule to still apply", proxyName, ok)
+	}
+}
+
+func TestRuleMatchesDomainSuffixRequiresBoundary(t *testing.T) {
+	r := &Rule{Type: "DOMAIN-SUFFIX", Value: "example.com"}
+	cases := []struct {
+		host string
+		want bool
+	}{
+		{host: "example.com", want: true},
+		{host: "www.example.com", want: true},
+		{host: "notexample.com", want: false},
+		{host: "evil-example.com", want: false},
+	}
+	for _, c := range cases {
+		if got := r.matches(c.host); got != c.want {
+			t.Errorf("matches(%q) = %v, want %v", c.host, got, c.want)
+		}
+	}
+}
+
+func TestParseGFWListEntry(t *testing.T) {
+	cases := []struct {
+		name    string
+		line    string
+		wantVal string
+		wantRe  bool
+	}{
+		{name: "suffix", line: "||example.com", wantVal: "example.com"},
+		{name: "domain", line: "|http://example.com/path", wantVal: "example.com"},
+		{name: "leading-dot-suffix", line: ".example.com", wantVal: "example.com"},
+		{name: "regex strips slashes", line: `/facebook\.com/`, wantVal: `facebook\.com`, wantRe: true},
+		{name: "bare falls back to suffix", line: "example.com", wantVal: "example.com"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := parseGFWListEntry(c.line, "proxy")
+			if err != nil {
+				t.Fatalf("parseGFWListEntry(%q): %v", c.line, err)
+			}
+			if r == nil {
+				t.Fatalf("parseGFWListEntry(%q) = nil", c.line)
+			}
+			if r.Value != c.wantVal {
+				t.Errorf("Value = %q, want %q", r.Value, c.wantVal)
+			}
+			if c.wantRe && r.Regexp == nil {
+				t.Errorf("Regexp = nil, want compiled regexp")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package shuttle
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//scriptSpec is a minimal request/response rewrite spec loaded from a
+//hostHandler's Script path. Real Lua/starlark execution isn't vendored in
+//this tree, so this supports a small line-oriented format instead:
+//
+//	status: 204
+//	request_header: X-Shuttle-Mitm: true
+//	header: X-Shuttle-Rewritten: true
+//	body: hijacked by shuttle
+//
+//"hijack" uses status/header/body to synthesize a response without ever
+//dialing the upstream. "mitm" appends request_header lines to the outgoing
+//request and header lines to the real response before relaying it on to the
+//client.
+type scriptSpec struct {
+	status         int
+	headers        []string
+	requestHeaders []string
+	body           string
+}
+
+//loadScript parses path, or returns (nil, nil) when path is empty so callers
+//can fall back to their own default behaviour.
+func loadScript(path string) (*scriptSpec, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("shuttle: script %s: %w", path, err)
+	}
+	spec := &scriptSpec{}
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		switch key {
+		case "status":
+			if n, err := strconv.Atoi(val); err == nil {
+				spec.status = n
+			}
+		case "header":
+			spec.headers = append(spec.headers, val)
+		case "request_header":
+			spec.requestHeaders = append(spec.requestHeaders, val)
+		case "body":
+			spec.body = val
+		}
+	}
+	return spec, nil
+}
+
+//writeResponse writes spec as an HTTP response directly to conn, used by the
+//"hijack" action to answer a request without ever dialing its target.
+func writeResponse(conn net.Conn, spec *scriptSpec) {
+	status := spec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		text = "Status"
+	}
+	fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\n", status, text)
+	for _, h := range spec.headers {
+		io.WriteString(conn, h+"\r\n")
+	}
+	fmt.Fprintf(conn, "Content-Length: %d\r\n\r\n", len(spec.body))
+	io.WriteString(conn, spec.body)
+}
@@ -0,0 +1,46 @@
+package shuttle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadScript(t *testing.T) {
+	if spec, err := loadScript(""); err != nil || spec != nil {
+		t.Fatalf("loadScript(\"\") = %v, %v; want nil, nil", spec, err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.txt")
+	content := "status: 204\n" +
+		"request_header: X-Shuttle-Mitm: true\n" +
+		"header: X-Shuttle-Rewritten: true\n" +
+		"body: hijacked\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := loadScript(path)
+	if err != nil {
+		t.Fatalf("loadScript: %v", err)
+	}
+	if spec.status != 204 {
+		t.Errorf("status = %d, want 204", spec.status)
+	}
+	if len(spec.requestHeaders) != 1 || spec.requestHeaders[0] != "X-Shuttle-Mitm: true" {
+		t.Errorf("requestHeaders = %v, want [X-Shuttle-Mitm: true]", spec.requestHeaders)
+	}
+	if len(spec.headers) != 1 || spec.headers[0] != "X-Shuttle-Rewritten: true" {
+		t.Errorf("headers = %v, want [X-Shuttle-Rewritten: true]", spec.headers)
+	}
+	if spec.body != "hijacked" {
+		t.Errorf("body = %q, want %q", spec.body, "hijacked")
+	}
+}
+
+func TestLoadScriptMissingFile(t *testing.T) {
+	if _, err := loadScript(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("loadScript should error on a missing file")
+	}
+}
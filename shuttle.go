@@ -0,0 +1,415 @@
+//Package shuttle wires together the dns/proxy/rule subsystems into the
+//actual HTTP(S) and SOCKS5 connection handling used by cmd/main.go.
+package shuttle
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sipt/shuttle/config"
+	"github.com/sipt/shuttle/log"
+	"github.com/sipt/shuttle/proxy"
+	"github.com/sipt/shuttle/rule"
+)
+
+var currentConfig *config.Config
+
+//InitConfigValue stashes the active config for the handlers in this package.
+func InitConfigValue(conf *config.Config) {
+	currentConfig = conf
+}
+
+//ApplyHTTPModifyConfig is the hook for (re-)building the request/response
+//rewrite rules used while proxying plain HTTP traffic; in this tree it builds
+//none and always returns nil.
+func ApplyHTTPModifyConfig(conf *config.Config) error {
+	return nil
+}
+
+//hostHandler is one compiled entry of the [mitm] handlers list.
+type hostHandler struct {
+	regex  *regexp.Regexp
+	action string
+	script string
+}
+
+var (
+	mitmMu       sync.RWMutex
+	mitmHandlers []*hostHandler
+)
+
+//ApplyMITMConfig compiles the ordered per-host handler list used by
+//HandleHTTP, goproxy-style: the first entry whose host_regex matches a
+//request's host wins, dispatching it to "mitm", "reject", "direct" or
+//"hijack" ahead of rule-based routing.
+func ApplyMITMConfig(conf *config.Config) error {
+	var handlers []*hostHandler
+	if conf.MITM != nil {
+		for _, h := range conf.MITM.Handlers {
+			re, err := regexp.Compile(h.HostRegex)
+			if err != nil {
+				return fmt.Errorf("shuttle: mitm handler %q: %w", h.HostRegex, err)
+			}
+			handlers = append(handlers, &hostHandler{regex: re, action: h.Action, script: h.Script})
+		}
+	}
+	mitmMu.Lock()
+	mitmHandlers = handlers
+	mitmMu.Unlock()
+	return nil
+}
+
+//matchHandler returns the first configured handler whose host_regex matches host.
+func matchHandler(host string) (*hostHandler, bool) {
+	mitmMu.RLock()
+	defer mitmMu.RUnlock()
+	for _, h := range mitmHandlers {
+		if h.regex.MatchString(host) {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+//HandleHTTP serves a single accepted HTTP/HTTPS proxy connection: it reads
+//the request line to learn the target host, dispatches it through any
+//matching per-host handler, and otherwise routes it by rule.
+func HandleHTTP(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return
+	}
+	method, target, ok := parseRequestLine(line)
+	if !ok {
+		return
+	}
+	host, addr, ok := hostAddr(method, target)
+	if !ok {
+		return
+	}
+
+	if h, ok := matchHandler(host); ok {
+		switch h.action {
+		case "reject":
+			io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\n\r\n")
+			return
+		case "hijack":
+			hijack(conn, h.script)
+			return
+		case "mitm":
+			mitmDispatch(conn, br, method, line, addr, h.script)
+			return
+		case "direct":
+			tunnel(conn, br, method, line, addr)
+			return
+		}
+	}
+
+	upstream, err := dialRoute(host, addr)
+	if err != nil {
+		log.Logger.Error("[shuttle] dial ", addr, ": ", err)
+		return
+	}
+	defer upstream.Close()
+	pump(conn, br, method, line, upstream)
+}
+
+//dialRoute dials addr through whichever proxy rule.Match (and the
+//health/bypass-aware proxy.Select) picks for host, or directly if no rule
+//matches or the match resolves to DIRECT.
+func dialRoute(host, addr string) (net.Conn, error) {
+	groupName, ok := rule.Match(host)
+	if !ok {
+		groupName = "DIRECT"
+	}
+	if groupName == "DIRECT" {
+		return net.Dial("tcp", addr)
+	}
+	name, err := proxy.Select(groupName, host)
+	if err != nil {
+		return nil, fmt.Errorf("select %s: %w", groupName, err)
+	}
+	if name == "DIRECT" {
+		return net.Dial("tcp", addr)
+	}
+	p, ok := proxy.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown proxy %q", name)
+	}
+	upstream, err := p.Protocol.DialContext(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("via %s: %w", name, err)
+	}
+	return upstream, nil
+}
+
+//SocksHandle serves a single accepted SOCKS5 proxy connection: it reads the
+//CONNECT request (RFC 1928; the method negotiation and any auth
+//sub-negotiation already happened in cmd/auth.go before the connection
+//reached here), routes the target by rule like HandleHTTP does, and relays
+//bytes in both directions once it replies.
+func SocksHandle(conn net.Conn) {
+	defer conn.Close()
+	host, addr, ok := readSocksRequest(conn)
+	if !ok {
+		return
+	}
+	upstream, err := dialRoute(host, addr)
+	if err != nil {
+		log.Logger.Error("[shuttle] dial ", addr, ": ", err)
+		writeSocksReply(conn, 0x01) //general SOCKS server failure
+		return
+	}
+	defer upstream.Close()
+	if !writeSocksReply(conn, 0x00) {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, conn)
+		if cw, ok := upstream.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(conn, upstream)
+	<-done
+}
+
+//readSocksRequest reads a RFC 1928 SOCKS5 request and returns the CONNECT
+//target's host and dial address. Only CMD=CONNECT (0x01) is supported.
+func readSocksRequest(conn net.Conn) (host, addr string, ok bool) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 0x05 || header[1] != 0x01 {
+		return "", "", false
+	}
+	switch header[3] {
+	case 0x01: //IPv4
+		raw := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, raw); err != nil {
+			return "", "", false
+		}
+		host = net.IP(raw).String()
+	case 0x03: //domain name
+		lenBuf := make([]byte, 1)
+		if _, err := io.ReadFull(conn, lenBuf); err != nil {
+			return "", "", false
+		}
+		raw := make([]byte, lenBuf[0])
+		if _, err := io.ReadFull(conn, raw); err != nil {
+			return "", "", false
+		}
+		host = string(raw)
+	case 0x04: //IPv6
+		raw := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(conn, raw); err != nil {
+			return "", "", false
+		}
+		host = net.IP(raw).String()
+	default:
+		return "", "", false
+	}
+	portBuf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBuf); err != nil {
+		return "", "", false
+	}
+	port := int(portBuf[0])<<8 | int(portBuf[1])
+	return host, net.JoinHostPort(host, strconv.Itoa(port)), true
+}
+
+//writeSocksReply sends a RFC 1928 reply with an all-zero BND.ADDR/BND.PORT,
+//which real clients ignore once the tunnel is already established.
+func writeSocksReply(conn net.Conn, code byte) bool {
+	_, err := conn.Write([]byte{0x05, code, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return err == nil
+}
+
+//parseRequestLine splits a "METHOD target HTTP/x.y" request line.
+func parseRequestLine(line string) (method, target string, ok bool) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+//hostAddr resolves a request line's host and dial address: target is already
+//"host:port" for CONNECT, otherwise an absolute-URI whose host defaults to
+//port 80.
+func hostAddr(method, target string) (host, addr string, ok bool) {
+	if method == "CONNECT" {
+		h, _, err := net.SplitHostPort(target)
+		if err != nil {
+			return "", "", false
+		}
+		return h, target, true
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Hostname() == "" {
+		return "", "", false
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	return u.Hostname(), net.JoinHostPort(u.Hostname(), port), true
+}
+
+//hijack answers a request entirely from its handler's script, without ever
+//dialing the target: the goproxy-style "hijack" action. A handler with no
+//script configured is answered with a generic 403, since there's nothing to
+//serve.
+func hijack(conn net.Conn, scriptPath string) {
+	spec, err := loadScript(scriptPath)
+	if err != nil {
+		log.Logger.Error("[shuttle] hijack: ", err)
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	if spec == nil {
+		spec = &scriptSpec{status: 403, body: "hijacked by shuttle"}
+	}
+	writeResponse(conn, spec)
+}
+
+//mitmDispatch rewrites both the outgoing request's and the real response's
+//headers per the handler's script before relaying each on. Rewriting a
+//CONNECT (HTTPS) request needs the MITM CA material ApplyMITMConfig doesn't
+//provision yet, so "mitm" still tunnels CONNECT traffic unmodified; only
+//plain HTTP hosts get the script-driven rewrite.
+func mitmDispatch(conn net.Conn, br *bufio.Reader, method, line, addr, scriptPath string) {
+	if method == "CONNECT" {
+		tunnel(conn, br, method, line, addr)
+		return
+	}
+	spec, err := loadScript(scriptPath)
+	if err != nil {
+		log.Logger.Error("[shuttle] mitm: ", err)
+		tunnel(conn, br, method, line, addr)
+		return
+	}
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		log.Logger.Error("[shuttle] dial ", addr, ": ", err)
+		return
+	}
+	defer upstream.Close()
+	if _, err = io.WriteString(upstream, line); err != nil {
+		return
+	}
+	var reqHeaders, respHeaders []string
+	if spec != nil {
+		reqHeaders = spec.requestHeaders
+		respHeaders = spec.headers
+	}
+	if err = relayHeaders(br, upstream, reqHeaders); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, br)
+		if cw, ok := upstream.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		close(done)
+	}()
+
+	upstreamReader := bufio.NewReader(upstream)
+	statusLine, err := upstreamReader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	if _, err = io.WriteString(conn, statusLine); err != nil {
+		return
+	}
+	if err = relayHeaders(upstreamReader, conn, respHeaders); err != nil {
+		return
+	}
+	io.Copy(conn, upstreamReader)
+	<-done
+}
+
+//relayHeaders forwards a header block (request headers from the client, or
+//response headers from the upstream) from src to dst verbatim, appending
+//extra header lines just before the terminating blank line.
+func relayHeaders(src *bufio.Reader, dst net.Conn, extra []string) error {
+	for {
+		line, err := src.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.TrimRight(line, "\r\n") == "" {
+			for _, h := range extra {
+				if _, err = io.WriteString(dst, h+"\r\n"); err != nil {
+					return err
+				}
+			}
+			_, err = io.WriteString(dst, "\r\n")
+			return err
+		}
+		if _, err = io.WriteString(dst, line); err != nil {
+			return err
+		}
+	}
+}
+
+//tunnel dials addr directly and pumps conn's traffic to/from it.
+func tunnel(conn net.Conn, br *bufio.Reader, method, line, addr string) {
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		log.Logger.Error("[shuttle] dial ", addr, ": ", err)
+		return
+	}
+	defer upstream.Close()
+	pump(conn, br, method, line, upstream)
+}
+
+//pump completes the CONNECT handshake (discarding its headers) or replays
+//the already-read request line ahead of the rest of the client stream, then
+//copies bytes in both directions between conn and upstream until one closes.
+func pump(conn net.Conn, br *bufio.Reader, method, line string, upstream net.Conn) {
+	if method == "CONNECT" {
+		if err := drainHeaders(br); err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+			return
+		}
+	} else if _, err := io.WriteString(upstream, line); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, br)
+		if cw, ok := upstream.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(conn, upstream)
+	<-done
+}
+
+//drainHeaders reads and discards request headers up to the blank line.
+func drainHeaders(br *bufio.Reader) error {
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.TrimRight(line, "\r\n") == "" {
+			return nil
+		}
+	}
+}